@@ -28,6 +28,11 @@ import (
 
 var SkipMissingTableOf = true
 
+// Gogo selects the gogo/protobuf runtime for .proto generation (see
+// protoType) and the protoc invocation in main. SaveFunctions and
+// SaveFunctionTests do not read this flag: the Go client and server
+// stubs they generate are the same in both modes (main logs a warning
+// about this when the google runtime is selected).
 var Gogo bool
 var NumberAsString bool
 
@@ -37,7 +42,15 @@ var NumberAsString bool
 // build: protoc --gofast_out=plugins=grpc:. my.proto
 // build: protoc --go_out=plugins=grpc:. my.proto
 
-func SaveProtobuf(dst io.Writer, functions []Function, pkg string) error {
+// SaveProtobuf writes the .proto definitions for functions as a single
+// file, rendering each message through the "message" template and the
+// enclosing service through the "service" template (see ActiveTemplates).
+//
+// annotations supplies the "http" entries (see -http) mapping a
+// function onto a REST path; withHTTP (set by -http-out) gates whether the service template
+// emits a google.api.http option at all, so output is unchanged when the
+// gateway is not requested.
+func SaveProtobuf(dst io.Writer, functions []Function, pkg string, annotations []Annotation, withHTTP bool) error {
 	var err error
 	w := errWriter{Writer: dst, err: &err}
 
@@ -49,11 +62,30 @@ func SaveProtobuf(dst io.Writer, functions []Function, pkg string) error {
 	if Gogo {
 		io.WriteString(w, `
 	import "github.com/gogo/protobuf/gogoproto/gogo.proto";
+`)
+	} else {
+		io.WriteString(w, `
+	import "google/protobuf/timestamp.proto";
+`)
+	}
+	if withHTTP {
+		io.WriteString(w, `	import "google/api/annotations.proto";
+`)
+	}
+	if usesWrapperTypes(functions) {
+		io.WriteString(w, `	import "google/protobuf/wrappers.proto";
 `)
 	}
 	seen := make(map[string]struct{}, 16)
 
-	services := make([]string, 0, len(functions))
+	httpByFunc := make(map[string]string, len(annotations))
+	for _, a := range annotations {
+		if a.Type == "http" {
+			httpByFunc[strings.ToLower(annotationKey(a))] = a.Other
+		}
+	}
+
+	svc := ServiceData{Name: CamelCase(pkg)}
 
 FunLoop:
 	for _, fun := range functions {
@@ -69,31 +101,61 @@ FunLoop:
 		if fun.HasCursorOut() {
 			streamQual = "stream "
 		}
-		name := CamelCase(dot2D.Replace(fName))
 		var comment string
 		if fun.Documentation != "" {
-			comment = "\n/// " + strings.Replace(fun.Documentation, "\n", "\n/// ", -1) + "\n\t"
+			comment = strings.Replace(fun.Documentation, "\n", "\n/// ", -1)
+		}
+		rpc := RPCData{
+			Comment:    comment,
+			Method:     CamelCase(dot2D.Replace(fName)),
+			Input:      CamelCase(fun.getStructName(false, false)),
+			Output:     CamelCase(fun.getStructName(true, false)),
+			StreamQual: streamQual,
+		}
+		if withHTTP {
+			if other, ok := httpByFunc[fName]; ok {
+				rpc.HTTPMethod, rpc.HTTPPath = splitHTTPAnnotation(other)
+			} else {
+				// fName is already the dotted "pkg.func" name, so turning
+				// its first dot into a slash gives "/v1/pkg/func" without
+				// repeating pkg or leaving a literal dot in the path.
+				rpc.HTTPMethod, rpc.HTTPPath = "post", "/v1/"+strings.Replace(fName, ".", "/", 1)
+			}
 		}
-		services = append(services,
-			fmt.Sprintf(`%srpc %s (%s) returns (%s%s) {}`,
-				comment,
-				name,
-				CamelCase(fun.getStructName(false, false)),
-				streamQual,
-				CamelCase(fun.getStructName(true, false)),
-			),
-		)
+		svc.RPCs = append(svc.RPCs, rpc)
 	}
 
-	fmt.Fprintf(w, "\nservice %s {\n", CamelCase(pkg))
-	for _, s := range services {
-		fmt.Fprintf(w, "\t%s\n", s)
+	if err := ActiveTemplates.render(w, "service", svc); err != nil {
+		return errors.Wrap(err, "render service template")
 	}
-	w.Write([]byte("}"))
 
 	return nil
 }
 
+// annotationKey reconstructs the dotted "pkg.func" name an Annotation
+// was parsed from (see main's -http/-replace parsing, which splits the
+// package prefix off into a.Package), so it can be matched back against
+// the fully-qualified, lower-cased function names SaveProtobuf works
+// with.
+func annotationKey(a Annotation) string {
+	if a.Package == "" {
+		return a.Name
+	}
+	return a.Package + "." + a.Name
+}
+
+// splitHTTPAnnotation parses an Annotation{Type:"http"}.Other value such
+// as "POST /v1/pkg/func" into the lower-cased google.api.http option
+// name ("post") and the path.
+func splitHTTPAnnotation(other string) (method, path string) {
+	other = strings.TrimSpace(other)
+	i := strings.IndexAny(other, " \t")
+	if i < 0 {
+		return "post", other
+	}
+	return strings.ToLower(other[:i]), strings.TrimSpace(other[i+1:])
+}
+
 func (f Function) SaveProtobuf(dst io.Writer, seen map[string]struct{}) error {
 	var buf bytes.Buffer
 	if err := f.saveProtobufDir(&buf, seen, false); err != nil {
@@ -135,9 +197,7 @@ func protoWriteMessageTyp(dst io.Writer, msgName string, seen map[string]struct{
 		}
 	}
 
-	var err error
-	w := errWriter{Writer: dst, err: &err}
-	fmt.Fprintf(w, "\nmessage %s {\n", msgName)
+	data := MessageData{Name: msgName}
 
 	buf := buffers.Get()
 	defer buffers.Put(buf)
@@ -169,7 +229,9 @@ func protoWriteMessageTyp(dst io.Writer, msgName string, seen map[string]struct{
 			optS = " " + s
 		}
 		if arg.Flavor == FLAVOR_SIMPLE || arg.Flavor == FLAVOR_TABLE && arg.TableOf.Flavor == FLAVOR_SIMPLE {
-			fmt.Fprintf(w, "\t// %s\n\t%s%s %s = %d%s;\n", arg.AbsType, rule, typ, aName, i+1, optS)
+			data.Fields = append(data.Fields, FieldData{
+				Comment: arg.AbsType, Rule: rule, Type: typ, Name: aName, Number: i + 1, Options: optS,
+			})
 			continue
 		}
 		typ = CamelCase(typ)
@@ -189,46 +251,129 @@ func protoWriteMessageTyp(dst io.Writer, msgName string, seen map[string]struct{
 					subArgs = append(subArgs, v.Argument)
 				}
 			}
-			if err = protoWriteMessageTyp(buf, typ, seen, subArgs...); err != nil {
+			if err := protoWriteMessageTyp(buf, typ, seen, subArgs...); err != nil {
 				Log("msg", "protoWriteMessageTyp", "error", err)
 				return err
 			}
 			seen[typ] = struct{}{}
 		}
-		fmt.Fprintf(w, "\t%s%s %s = %d%s;\n", rule, typ, aName, i+1, optS)
+		data.Fields = append(data.Fields, FieldData{Rule: rule, Type: typ, Name: aName, Number: i + 1, Options: optS})
 	}
-	io.WriteString(w, "}\n")
-	w.Write(buf.Bytes())
 
+	if err := ActiveTemplates.render(dst, "message", data); err != nil {
+		return errors.Wrap(err, "render message template")
+	}
+	_, err := dst.Write(buf.Bytes())
 	return err
 }
 
+// usesWrapperTypes reports whether rendering functions would emit any
+// google.protobuf.*Value wrapper type (see protoType's sql.Null* cases),
+// i.e. whether the .proto needs to import wrappers.proto. Gogo mode
+// never emits wrapper types, so it's always false there.
+func usesWrapperTypes(functions []Function) bool {
+	if Gogo {
+		return false
+	}
+	for _, f := range functions {
+		for _, a := range f.Args {
+			if argUsesWrapperType(a) {
+				return true
+			}
+		}
+		if f.Returns != nil && argUsesWrapperType(*f.Returns) {
+			return true
+		}
+	}
+	return false
+}
+
+func argUsesWrapperType(a Argument) bool {
+	switch strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(a.goType(false), "[]"), "*")) {
+	case "sql.nullfloat64", "sql.nullint32", "sql.nullint64", "sql.nullstring", "sql.nullbool":
+		return true
+	}
+	if a.TableOf != nil {
+		return argUsesWrapperType(*a.TableOf)
+	}
+	for _, v := range a.RecordOf {
+		if argUsesWrapperType(v.Argument) {
+			return true
+		}
+	}
+	return false
+}
+
+// protoType maps a Go type name to its Protocol Buffers counterpart and
+// any field options needed to round-trip it. With Gogo, gogoproto.jsontag
+// forces a specific Go struct json tag; google.golang.org/protobuf has no
+// such struct-tag hook, so in that mode we fall back to the standard
+// json_name field option, which only affects the canonical JSON field
+// name, not the Go struct tag.
 func protoType(got, aName string) (string, protoOptions) {
 	switch trimmed := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(got, "[]"), "*")); trimmed {
 	case "time.time":
-		return "string", nil
+		if Gogo {
+			return "string", nil
+		}
+		return "google.protobuf.Timestamp", nil
 	case "string":
 		return "string", nil
 
 	case "int32":
 		if NumberAsString {
-			return "sint32", protoOptions{
-				"gogoproto.jsontag": aName + ",string,omitempty",
+			if Gogo {
+				return "sint32", protoOptions{"gogoproto.jsontag": aName + ",string,omitempty"}
 			}
+			return "sint32", protoOptions{"json_name": aName}
 		}
 		return "sint32", nil
-	case "float64", "sql.nullfloat64":
+	case "float64":
 		if NumberAsString {
-			return "double", protoOptions{
-				"gogoproto.jsontag": aName + ",string,omitempty",
+			if Gogo {
+				return "double", protoOptions{"gogoproto.jsontag": aName + ",string,omitempty"}
 			}
+			return "double", protoOptions{"json_name": aName}
 		}
 		return "double", nil
 
+	// The sql.Null* Go types distinguish NULL from the zero value; proto3
+	// scalars can't, so in google mode we use the matching well-known
+	// wrapper type instead of the bare scalar gogo used.
+	case "sql.nullfloat64":
+		if Gogo {
+			if NumberAsString {
+				return "double", protoOptions{"gogoproto.jsontag": aName + ",string,omitempty"}
+			}
+			return "double", nil
+		}
+		return "google.protobuf.DoubleValue", nil
+	case "sql.nullint32":
+		if Gogo {
+			return "sint32", nil
+		}
+		return "google.protobuf.Int32Value", nil
+	case "sql.nullint64":
+		if Gogo {
+			return "sint64", nil
+		}
+		return "google.protobuf.Int64Value", nil
+	case "sql.nullstring":
+		if Gogo {
+			return "string", nil
+		}
+		return "google.protobuf.StringValue", nil
+	case "sql.nullbool":
+		if Gogo {
+			return "bool", nil
+		}
+		return "google.protobuf.BoolValue", nil
+
 	case "goracle.number":
-		return "string", protoOptions{
-			"gogoproto.jsontag": aName + ",omitempty",
+		if Gogo {
+			return "string", protoOptions{"gogoproto.jsontag": aName + ",omitempty"}
 		}
+		return "string", protoOptions{"json_name": aName}
 
 	case "custom.date":
 		return "string", nil
@@ -253,7 +398,13 @@ func (opts protoOptions) String() string {
 		if buf.Len() != 1 {
 			buf.WriteString(", ")
 		}
-		fmt.Fprintf(&buf, "(%s)=", k)
+		// json_name is a standard proto3 field option, not a custom
+		// extension, so it is written without the (pkg.name) syntax.
+		if k == "json_name" {
+			buf.WriteString("json_name=")
+		} else {
+			fmt.Fprintf(&buf, "(%s)=", k)
+		}
 		switch v.(type) {
 		case bool:
 			fmt.Fprintf(&buf, "%t", v)
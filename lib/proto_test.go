@@ -0,0 +1,52 @@
+/*
+Copyright 2026 Tamás Gulácsi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oracall
+
+import "testing"
+
+func TestSplitHTTPAnnotation(t *testing.T) {
+	for _, tc := range []struct {
+		in         string
+		wantMethod string
+		wantPath   string
+	}{
+		{"POST /v1/pkg/func", "post", "/v1/pkg/func"},
+		{"  GET  /v1/pkg/func  ", "get", "/v1/pkg/func"},
+		{"/v1/pkg/func", "post", "/v1/pkg/func"},
+		{"delete /v1/pkg/func", "delete", "/v1/pkg/func"},
+	} {
+		gotMethod, gotPath := splitHTTPAnnotation(tc.in)
+		if gotMethod != tc.wantMethod || gotPath != tc.wantPath {
+			t.Errorf("splitHTTPAnnotation(%q) = (%q, %q), want (%q, %q)",
+				tc.in, gotMethod, gotPath, tc.wantMethod, tc.wantPath)
+		}
+	}
+}
+
+func TestAnnotationKey(t *testing.T) {
+	for _, tc := range []struct {
+		a    Annotation
+		want string
+	}{
+		{Annotation{Package: "mypkg", Name: "myfunc"}, "mypkg.myfunc"},
+		{Annotation{Name: "myfunc"}, "myfunc"},
+	} {
+		if got := annotationKey(tc.a); got != tc.want {
+			t.Errorf("annotationKey(%+v) = %q, want %q", tc.a, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2021 Tamás Gulácsi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oracall
+
+import (
+	"embed"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// defaultTemplateFS holds the built-in templates that reproduce the
+// historical fmt.Fprintf/io.WriteString-based output, so behavior is
+// preserved when no -template-dir is given.
+//
+//go:embed templates/*.tmpl
+var defaultTemplateFS embed.FS
+
+// templateFuncs is the funcmap shared by every proto template,
+// user-supplied or built-in. It is intentionally small and stable so
+// external templates keep working across releases.
+var templateFuncs = template.FuncMap{
+	"camelCase":    CamelCase,
+	"mkRecTypName": mkRecTypName,
+	"protoType": func(got, name string) string {
+		typ, _ := protoType(got, name)
+		return typ
+	},
+	"protoOptions": func(got, name string) string {
+		_, opts := protoType(got, name)
+		return opts.String()
+	},
+	// httpHasBody reports whether the google.api.http option for method
+	// should carry a body; GET/DELETE/HEAD are bodyless by convention.
+	"httpHasBody": func(method string) bool {
+		switch strings.ToLower(method) {
+		case "post", "put", "patch":
+			return true
+		default:
+			return false
+		}
+	},
+}
+
+// Templates is the parsed text/template set used to render generated
+// Go and Protocol Buffers sources. The zero value is not usable; obtain
+// one from DefaultTemplates or LoadTemplates.
+type Templates struct {
+	*template.Template
+}
+
+func (t Templates) render(w io.Writer, name string, data interface{}) error {
+	return t.ExecuteTemplate(w, name, data)
+}
+
+// DefaultTemplates parses and returns the templates embedded at compile
+// time: service.proto.tmpl and message.proto.tmpl.
+func DefaultTemplates() (Templates, error) {
+	tmpl, err := template.New("oracall").Funcs(templateFuncs).ParseFS(defaultTemplateFS, "templates/*.tmpl")
+	if err != nil {
+		return Templates{}, errors.Wrap(err, "parse default templates")
+	}
+	return Templates{tmpl}, nil
+}
+
+// LoadTemplates returns the default templates with every *.tmpl file
+// found directly under dir parsed on top, so a user only needs to ship
+// the template they want to override (e.g. just service.proto.tmpl to
+// change how RPCs are rendered, without touching message.proto.tmpl).
+// An empty dir returns DefaultTemplates unchanged.
+func LoadTemplates(dir string) (Templates, error) {
+	tmpl, err := DefaultTemplates()
+	if err != nil {
+		return Templates{}, err
+	}
+	if dir == "" {
+		return tmpl, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return Templates{}, errors.Wrapf(err, "glob %q", dir)
+	}
+	if len(matches) == 0 {
+		return tmpl, nil
+	}
+	if tmpl.Template, err = tmpl.Template.ParseFiles(matches...); err != nil {
+		return Templates{}, errors.Wrapf(err, "parse %q", dir)
+	}
+	return tmpl, nil
+}
+
+// ActiveTemplates is the template set consulted by SaveProtobuf. It
+// defaults to the embedded templates and is meant to be replaced
+// wholesale from main (via LoadTemplates), the same way Gogo and
+// NumberAsString are configured.
+//
+// SaveFunctions and SaveFunctionTests are not yet routed through
+// templates; -template-dir only affects the .proto output for now.
+var ActiveTemplates = mustDefaultTemplates()
+
+func mustDefaultTemplates() Templates {
+	tmpl, err := DefaultTemplates()
+	if err != nil {
+		panic(err)
+	}
+	return tmpl
+}
+
+// MessageData is the data model exposed to the "message" proto
+// template: a single Protocol Buffers message and its fields.
+type MessageData struct {
+	Name   string
+	Fields []FieldData
+}
+
+// FieldData describes one field of a generated Protocol Buffers message.
+type FieldData struct {
+	Comment string
+	Rule    string
+	Type    string
+	Name    string
+	Number  int
+	Options string
+}
+
+// ServiceData is the data model exposed to the "service" proto
+// template: the RPCs generated for one PL/SQL package.
+type ServiceData struct {
+	Name string
+	RPCs []RPCData
+}
+
+// RPCData describes a single generated rpc method.
+type RPCData struct {
+	Comment    string
+	Method     string
+	Input      string
+	Output     string
+	StreamQual string
+	// HTTPMethod and HTTPPath are set when REST/OpenAPI annotations are
+	// enabled (see -http-out); HTTPMethod is empty otherwise and the
+	// "service" template must omit the google.api.http option.
+	HTTPMethod string
+	HTTPPath   string
+}
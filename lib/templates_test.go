@@ -0,0 +1,53 @@
+/*
+Copyright 2026 Tamás Gulácsi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oracall
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestMessageTemplateFieldIndent is a golden-file-style check that the
+// default "message" template emits exactly one leading tab per field
+// line, whether or not the field has a Comment: a doubled tab crept in
+// during the text/template rewrite of message.proto.tmpl and would otherwise only
+// show up as a mangled .proto in manual testing.
+func TestMessageTemplateFieldIndent(t *testing.T) {
+	tmpl, err := DefaultTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := MessageData{
+		Name: "Foo",
+		Fields: []FieldData{
+			{Type: "string", Name: "bar", Number: 1},
+			{Comment: "baz comment", Type: "string", Name: "baz", Number: 2},
+		},
+	}
+	var buf bytes.Buffer
+	if err := tmpl.render(&buf, "message", data); err != nil {
+		t.Fatal(err)
+	}
+	want := "\nmessage Foo {\n" +
+		"\tstring bar = 1;\n" +
+		"\t// baz comment\n" +
+		"\tstring baz = 2;\n" +
+		"}\n"
+	if got := buf.String(); got != want {
+		t.Errorf("message template output = %q, want %q", got, want)
+	}
+}
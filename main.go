@@ -72,13 +72,20 @@ func Main(args []string) error {
 	flagBaseDir := flag.String("base-dir", gopSrc, "base dir for the -pb-out, -db-out flags")
 	flagPbOut := flag.String("pb-out", "", "package import path for the Protocol Buffers files, optionally with the package name, like \"my/pb-pkg:main\"")
 	flagDbOut := flag.String("db-out", "-:main", "package name of the generated functions, optionally with the package name, like \"my/db-pkg:main\"")
-	flagGenerator := flag.String("protoc-gen", "gogofast", "use protoc-gen-<generator>")
+	flagGenerator := flag.String("protoc-gen", "gogofast", "use protoc-gen-<generator> (only used with -proto-runtime=gogo)")
+	flagProtoRuntime := flag.String("proto-runtime", "google", `protobuf runtime to target: "google" (google.golang.org/protobuf + protoc-gen-go-grpc) or "gogo" (github.com/gogo/protobuf, kept for backwards compatibility)`)
 	flag.BoolVar(&genocall.NumberAsString, "number-as-string", false, "add ,string to json tags")
 	flag.BoolVar(&custom.ZeroIsAlmostZero, "zero-is-almost-zero", false, "zero should be just almost zero, to distinguish 0 and non-set field")
 	flagVerbose := flag.Bool("v", false, "verbose logging")
 	flagExcept := flag.String("except", "", "except these functions")
 	flagReplace := flag.String("replace", "", "funcA=>funcB")
+	flagHTTP := flag.String("http", "", "pkg.func=>METHOD /path, comma separated; maps a function onto a REST path for -http-out")
+	flagHTTPOut := flag.String("http-out", "", "package import path for grpc-gateway stubs; when set, emits google.api.http annotations and runs protoc-gen-grpc-gateway, same format as -pb-out")
+	flagOpenAPIOut := flag.String("openapi-out", "", "directory to write an OpenAPI v2 document via protoc-gen-openapiv2; requires -http-out")
 	flag.IntVar(&genocall.MaxTableSize, "max-table-size", genocall.MaxTableSize, "maximum table size for PL/SQL associative arrays")
+	flagTemplateDir := flag.String("template-dir", "", "directory of user-supplied text/template files (service.proto.tmpl, message.proto.tmpl) overriding the built-in .proto output")
+	flagTemplateProto := flag.String("template-proto", "", "extra template file to parse on top of -template-dir, for the service/message proto templates")
+	flagRepl := flag.Bool("repl", false, "after reading the DB, explore the matched packages/functions interactively before generating code")
 
 	flag.Parse()
 	if *flagPbOut == "" {
@@ -92,18 +99,44 @@ func Main(args []string) error {
 	pbPath, pbPkg := parsePkgFlag(*flagPbOut)
 	dbPath, dbPkg := parsePkgFlag(*flagDbOut)
 
+	tmpl, err := genocall.LoadTemplates(*flagTemplateDir)
+	if err != nil {
+		return errors.Errorf("load templates: %w", err)
+	}
+	if *flagTemplateProto != "" {
+		if tmpl.Template, err = tmpl.Template.ParseFiles(*flagTemplateProto); err != nil {
+			return errors.Errorf("parse template %q: %w", *flagTemplateProto, err)
+		}
+	}
+	genocall.ActiveTemplates = tmpl
+
 	Log := logger.Log
 	pattern := flag.Arg(0)
 	if pattern == "" {
 		pattern = "%"
 	}
-	genocall.Gogo = *flagGenerator != "go"
+	switch *flagProtoRuntime {
+	case "gogo":
+		genocall.Gogo = true
+	case "google", "":
+		genocall.Gogo = false
+	default:
+		return errors.Errorf("-proto-runtime: unknown value %q, want \"gogo\" or \"google\"", *flagProtoRuntime)
+	}
+	if !genocall.Gogo {
+		// SaveFunctions/SaveFunctionTests still emit gogo/protobuf-style
+		// client/server stubs; they don't yet switch to
+		// google.golang.org/grpc under -proto-runtime=google (the
+		// default). Surface this loudly instead of letting the mismatch
+		// between the .proto (google mode) and the generated Go (gogo
+		// mode) show up only as a build failure downstream.
+		Log("msg", "SaveFunctions/SaveFunctionTests do not yet follow -proto-runtime=google; generated Go stubs still target the gogo/protobuf runtime", "proto-runtime", *flagProtoRuntime)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 
 	var functions []genocall.Function
-	var err error
 
 	filters := [](func(string) bool){func(string) bool { return true }}
 	filter := func(s string) bool {
@@ -190,10 +223,39 @@ func Main(args []string) error {
 		}
 		annotations = append(annotations, a)
 	}
+	withHTTP := *flagHTTPOut != ""
+	*flagHTTP = strings.TrimSpace(*flagHTTP)
+	if *flagHTTP != "" {
+		for _, elt := range strings.Split(*flagHTTP, ",") {
+			elt = strings.TrimSpace(elt)
+			if elt == "" {
+				continue
+			}
+			i := strings.Index(elt, "=>")
+			if i < 0 {
+				continue
+			}
+			a := genocall.Annotation{Type: "http", Name: strings.TrimSpace(elt[:i]), Other: strings.TrimSpace(elt[i+2:])}
+			if i = strings.IndexByte(a.Name, '.'); i >= 0 {
+				a.Package, a.Name = a.Name[:i], a.Name[i+1:]
+			}
+			annotations = append(annotations, a)
+		}
+	}
 	Log("annotations", annotations)
 	functions = genocall.ApplyAnnotations(functions, annotations)
 	sort.Slice(functions, func(i, j int) bool { return functions[i].Name() < functions[j].Name() })
 
+	if *flagRepl {
+		var commit bool
+		if functions, annotations, commit, err = runREPL(functions, annotations); err != nil {
+			return errors.Errorf("repl: %w", err)
+		}
+		if !commit {
+			return nil
+		}
+	}
+
 	var grp errgroup.Group
 	grp.Go(func() error {
 		pbPath := pbPath
@@ -236,7 +298,7 @@ func Main(args []string) error {
 		if err != nil {
 			return errors.Errorf("create proto: %w", err)
 		}
-		err = genocall.SaveProtobuf(fh, functions, pbPkg)
+		err = genocall.SaveProtobuf(fh, functions, pbPkg, annotations, withHTTP)
 		if closeErr := fh.Close(); closeErr != nil && err == nil {
 			err = closeErr
 		}
@@ -244,17 +306,70 @@ func Main(args []string) error {
 			return errors.Errorf("SaveProtobuf: %w", err)
 		}
 
-		goOut := *flagGenerator + "_out"
-		cmd := exec.Command(
-			"protoc",
-			"--proto_path="+*flagBaseDir+":.",
-			"--"+goOut+"=Mgoogle/protobuf/timestamp.proto=github.com/gogo/protobuf/types,plugins=grpc:"+*flagBaseDir,
-			fn,
-		)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return errors.Errorf("%q: %w", cmd.Args, err)
+		if genocall.Gogo {
+			goOut := *flagGenerator + "_out"
+			cmd := exec.Command(
+				"protoc",
+				"--proto_path="+*flagBaseDir+":.",
+				"--"+goOut+"=Mgoogle/protobuf/timestamp.proto=github.com/gogo/protobuf/types,plugins=grpc:"+*flagBaseDir,
+				fn,
+			)
+			cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+			if err := cmd.Run(); err != nil {
+				return errors.Errorf("%q: %w", cmd.Args, err)
+			}
+		} else {
+			goCmd := exec.Command(
+				"protoc",
+				"--proto_path="+*flagBaseDir+":.",
+				"--go_out=Mgoogle/protobuf/timestamp.proto=google.golang.org/protobuf/types/known/timestamppb,paths=source_relative:"+*flagBaseDir,
+				fn,
+			)
+			goCmd.Stdout, goCmd.Stderr = os.Stdout, os.Stderr
+			if err := goCmd.Run(); err != nil {
+				return errors.Errorf("%q: %w", goCmd.Args, err)
+			}
+
+			grpcCmd := exec.Command(
+				"protoc",
+				"--proto_path="+*flagBaseDir+":.",
+				"--go-grpc_out=paths=source_relative:"+*flagBaseDir,
+				fn,
+			)
+			grpcCmd.Stdout, grpcCmd.Stderr = os.Stdout, os.Stderr
+			if err := grpcCmd.Run(); err != nil {
+				return errors.Errorf("%q: %w", grpcCmd.Args, err)
+			}
+		}
+
+		if withHTTP {
+			httpPath, _ := parsePkgFlag(*flagHTTPOut)
+			httpDir := filepath.Join(*flagBaseDir, httpPath)
+			os.MkdirAll(httpDir, 0775)
+			gwCmd := exec.Command(
+				"protoc",
+				"--proto_path="+*flagBaseDir+":.",
+				"--grpc-gateway_out=logtostderr=true,paths=source_relative:"+httpDir,
+				fn,
+			)
+			gwCmd.Stdout, gwCmd.Stderr = os.Stdout, os.Stderr
+			if err := gwCmd.Run(); err != nil {
+				return errors.Errorf("%q: %w", gwCmd.Args, err)
+			}
+
+			if *flagOpenAPIOut != "" {
+				os.MkdirAll(*flagOpenAPIOut, 0775)
+				oaCmd := exec.Command(
+					"protoc",
+					"--proto_path="+*flagBaseDir+":.",
+					"--openapiv2_out=logtostderr=true:"+*flagOpenAPIOut,
+					fn,
+				)
+				oaCmd.Stdout, oaCmd.Stderr = os.Stdout, os.Stderr
+				if err := oaCmd.Run(); err != nil {
+					return errors.Errorf("%q: %w", oaCmd.Args, err)
+				}
+			}
 		}
 		return nil
 	})
@@ -0,0 +1,262 @@
+/*
+Copyright 2021 Tamás Gulácsi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	genocall "github.com/godror/gen-o-call/lib"
+	"github.com/peterh/liner"
+	errors "golang.org/x/xerrors"
+)
+
+const replHistoryFile = ".genocall_history"
+
+// runREPL drives an interactive session over the already-introspected
+// functions, so a developer can list/describe/preview them and tweak
+// the -except selection live before committing it to disk. It returns
+// the (possibly narrowed) function list and whether the user asked to
+// commit it; a false commit with a nil error means the user quit
+// without generating anything.
+func runREPL(functions []genocall.Function, annotations []genocall.Annotation) ([]genocall.Function, []genocall.Annotation, bool, error) {
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+
+	names := make([]string, len(functions))
+	byName := make(map[string]genocall.Function, len(functions))
+	for i, f := range functions {
+		names[i] = f.Name()
+		byName[strings.ToLower(f.Name())] = f
+	}
+	sort.Strings(names)
+	// SetWordCompleter, not SetCompleter: every command here is
+	// multi-word ("describe <func>", "preview <func>", ...), and
+	// SetCompleter would try to prefix-match the whole line against
+	// bare function names.
+	line.SetWordCompleter(func(line string, pos int) (head string, completions []string, tail string) {
+		head, word, tail := splitLastWord(line, pos)
+		for _, n := range names {
+			if strings.HasPrefix(strings.ToLower(n), strings.ToLower(word)) {
+				completions = append(completions, n)
+			}
+		}
+		return head, completions, tail
+	})
+
+	histPath := replHistoryPath()
+	if histPath != "" {
+		if f, err := os.Open(histPath); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+		defer func() {
+			if f, err := os.Create(histPath); err == nil {
+				line.WriteHistory(f)
+				f.Close()
+			}
+		}()
+	}
+
+	except := make(map[string]struct{}, 16)
+	fmt.Println("genocall repl: list, describe <func>, preview <func>, except <func>, include <func>,")
+	fmt.Println("               replace <pkg.funcA>=><pkg.funcB>, max-table-size <n>, skip-missing-table-of <bool>, commit, quit")
+	for {
+		cmd, err := line.Prompt("genocall> ")
+		if err != nil {
+			if err == liner.ErrPromptAborted || err == io.EOF {
+				return functions, annotations, false, nil
+			}
+			return nil, nil, false, errors.Errorf("prompt: %w", err)
+		}
+		line.AppendHistory(cmd)
+		fields := strings.Fields(cmd)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "list":
+			for _, n := range names {
+				if _, skip := except[strings.ToLower(n)]; !skip {
+					fmt.Println(n)
+				}
+			}
+		case "describe":
+			if len(fields) < 2 {
+				fmt.Println("usage: describe <func>")
+				continue
+			}
+			f, ok := byName[strings.ToLower(fields[1])]
+			if !ok {
+				fmt.Printf("unknown function %q\n", fields[1])
+				continue
+			}
+			describeFunction(os.Stdout, f)
+		case "preview":
+			if len(fields) < 2 {
+				fmt.Println("usage: preview <func>")
+				continue
+			}
+			f, ok := byName[strings.ToLower(fields[1])]
+			if !ok {
+				fmt.Printf("unknown function %q\n", fields[1])
+				continue
+			}
+			if err := previewFunction(os.Stdout, f); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		case "except":
+			for _, n := range fields[1:] {
+				except[strings.ToLower(n)] = struct{}{}
+			}
+		case "include":
+			for _, n := range fields[1:] {
+				delete(except, strings.ToLower(n))
+			}
+		case "replace":
+			if len(fields) < 2 {
+				fmt.Println("usage: replace <pkg.funcA>=><pkg.funcB>")
+				continue
+			}
+			i := strings.Index(fields[1], "=>")
+			if i < 0 {
+				fmt.Println("usage: replace <pkg.funcA>=><pkg.funcB>")
+				continue
+			}
+			a := genocall.Annotation{Type: "replace", Name: fields[1][:i], Other: fields[1][i+2:]}
+			if i = strings.IndexByte(a.Name, '.'); i >= 0 {
+				a.Package, a.Name = a.Name[:i], a.Name[i+1:]
+				a.Other = strings.TrimPrefix(a.Other, a.Package)
+			}
+			annotations = append(annotations, a)
+			functions = genocall.ApplyAnnotations(functions, annotations)
+			names = names[:0]
+			byName = make(map[string]genocall.Function, len(functions))
+			for _, f := range functions {
+				names = append(names, f.Name())
+				byName[strings.ToLower(f.Name())] = f
+			}
+			sort.Strings(names)
+		case "max-table-size":
+			if len(fields) < 2 {
+				fmt.Printf("max-table-size is %d\n", genocall.MaxTableSize)
+				continue
+			}
+			n, err := strconv.Atoi(fields[1])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			genocall.MaxTableSize = n
+		case "skip-missing-table-of":
+			if len(fields) < 2 {
+				fmt.Printf("skip-missing-table-of is %t\n", genocall.SkipMissingTableOf)
+				continue
+			}
+			b, err := strconv.ParseBool(fields[1])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			genocall.SkipMissingTableOf = b
+		case "commit":
+			selected := make([]genocall.Function, 0, len(functions))
+			for _, f := range functions {
+				if _, skip := except[strings.ToLower(f.Name())]; !skip {
+					selected = append(selected, f)
+				}
+			}
+			return selected, annotations, true, nil
+		case "quit", "exit":
+			return functions, annotations, false, nil
+		default:
+			fmt.Printf("unknown command %q (try list, describe, preview, except, include, replace, max-table-size, skip-missing-table-of, commit, quit)\n", fields[0])
+		}
+	}
+}
+
+// describeFunction prints f's Args/Returns tree, walking RecordOf/TableOf
+// children. A table's element is printed as a level of its own, whereas
+// protoWriteMessageTyp folds it into the repeated field, so the tree shown
+// here has one level more per table than the messages SaveProtobuf emits.
+func describeFunction(w io.Writer, f genocall.Function) {
+	fmt.Fprintf(w, "%s\n", f.Name())
+	for _, a := range f.Args {
+		describeArgument(w, "  ", a)
+	}
+	if f.Returns != nil {
+		fmt.Fprintf(w, "  returns:\n")
+		describeArgument(w, "    ", *f.Returns)
+	}
+}
+
+// describeArgument prints one argument and recurses into its TableOf or
+// RecordOf children, if any, at one deeper indent.
+func describeArgument(w io.Writer, indent string, a genocall.Argument) {
+	fmt.Fprintf(w, "%s%s %s\n", indent, a.Name, a.AbsType)
+	switch {
+	case a.TableOf != nil:
+		describeArgument(w, indent+"  ", *a.TableOf)
+	case len(a.RecordOf) > 0:
+		for _, v := range a.RecordOf {
+			describeArgument(w, indent+"  ", v.Argument)
+		}
+	}
+}
+
+// previewFunction renders the .proto messages and service block for a
+// single function, without touching disk. The function's own name is used
+// as the package and service name, and no google.api.http options are
+// emitted, so those lines differ from what a full run writes.
+//
+// There's no Go-wrapper counterpart to preview here: SaveFunctions isn't
+// routed through ActiveTemplates yet (see lib/templates.go), so the only
+// generated-Go-side output this repl can show is the .proto it feeds to
+// protoc.
+func previewFunction(w io.Writer, f genocall.Function) error {
+	return genocall.SaveProtobuf(w, []genocall.Function{f}, f.Name(), nil, false)
+}
+
+// splitLastWord splits line at pos into the text before the word under
+// the cursor, the word itself, and the text after it, so a WordCompleter
+// can complete just the trailing word of a multi-word command.
+func splitLastWord(line string, pos int) (head, word, tail string) {
+	head = line[:pos]
+	tail = line[pos:]
+	if i := strings.LastIndexAny(head, " \t"); i >= 0 {
+		word = head[i+1:]
+		head = head[:i+1]
+	} else {
+		word = head
+		head = ""
+	}
+	return head, word, tail
+}
+
+func replHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, replHistoryFile)
+}